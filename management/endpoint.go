@@ -0,0 +1,169 @@
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/go-kit/kit/endpoint"
+	"golang.org/x/net/context"
+)
+
+var (
+	errEmptyRequest = errors.New("request must contain valid data")
+	errBadRouting   = errors.New("inconsistent mapping between route and handler (programmer error)")
+)
+
+func makeFetchDevicesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(fetchDEPDevicesRequest)
+		devices, next, err := svc.FetchDEPDevices(ctx, req.ListOpts)
+		return fetchDEPDevicesResponse{Devices: devices, Fields: req.Fields, NextCursor: next, query: req.query, Err: err}, nil
+	}
+}
+
+func makeAddProfileEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(addProfileRequest)
+		p, err := svc.NewProfile(ctx, req.Profile)
+		return addProfileResponse{Profile: p, Err: err}, nil
+	}
+}
+
+func makeListProfilesEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(listProfilesRequest)
+		profiles, next, err := svc.Profiles(ctx, req.ListOpts)
+		return listProfilesResponse{Profiles: profiles, Fields: req.Fields, NextCursor: next, query: req.query, Err: err}, nil
+	}
+}
+
+// makeUpdateProfileEndpoint handles a full replacement of a profile
+// (PUT). It's hand-written rather than mdmgen-generated because, like
+// POST, its body is {mobileconfig, encoding} rather than a plain
+// Profile - ReplaceProfile validates and derives the rest of the
+// profile from the mobileconfig itself.
+func makeUpdateProfileEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(updateProfileRequest)
+		p, err := svc.ReplaceProfile(ctx, req.UUID, req.Profile)
+		return updateProfileResponse{Profile: p, Err: err}, nil
+	}
+}
+
+// makePatchProfileEndpoint handles a partial update of a profile (PATCH).
+func makePatchProfileEndpoint(svc Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(patchProfileRequest)
+		p, err := svc.PatchProfile(ctx, req.UUID, req.Patch)
+		return patchProfileResponse{Profile: p, Err: err}, nil
+	}
+}
+
+type fetchDEPDevicesRequest struct {
+	ListOpts
+	query url.Values
+}
+
+type fetchDEPDevicesResponse struct {
+	Devices    []DEPDevice `json:"devices,omitempty"`
+	Fields     []string    `json:"-"`
+	NextCursor string      `json:"-"`
+	Err        error       `json:"error,omitempty"`
+	query      url.Values
+}
+
+func (r fetchDEPDevicesResponse) error() error { return r.Err }
+func (r fetchDEPDevicesResponse) linkHeader() string {
+	return nextLinkHeader("/management/v1/devices/fetch", r.query, r.NextCursor)
+}
+func (r fetchDEPDevicesResponse) encodeList(w http.ResponseWriter) error {
+	if len(r.Fields) == 0 {
+		return json.NewEncoder(w).Encode(r.Devices)
+	}
+	projected := make([]map[string]interface{}, len(r.Devices))
+	for i, d := range r.Devices {
+		pf, err := projectFields(d, r.Fields)
+		if err != nil {
+			return err
+		}
+		projected[i] = pf
+	}
+	return json.NewEncoder(w).Encode(projected)
+}
+
+type addProfileRequest struct {
+	Profile
+}
+
+type addProfileResponse struct {
+	Profile *Profile `json:"profile,omitempty"`
+	Err     error    `json:"error,omitempty"`
+}
+
+func (r addProfileResponse) error() error { return r.Err }
+func (r addProfileResponse) status() int  { return http.StatusCreated }
+
+type listProfilesRequest struct {
+	ListOpts
+	query url.Values
+}
+
+type listProfilesResponse struct {
+	Profiles   []Profile `json:"profiles,omitempty"`
+	Fields     []string  `json:"-"`
+	NextCursor string    `json:"-"`
+	Err        error     `json:"error,omitempty"`
+	query      url.Values
+}
+
+func (r listProfilesResponse) error() error { return r.Err }
+func (r listProfilesResponse) linkHeader() string {
+	return nextLinkHeader("/management/v1/profiles", r.query, r.NextCursor)
+}
+func (r listProfilesResponse) encodeList(w http.ResponseWriter) error {
+	if len(r.Fields) == 0 {
+		return json.NewEncoder(w).Encode(r.Profiles)
+	}
+	projected := make([]map[string]interface{}, len(r.Profiles))
+	for i, p := range r.Profiles {
+		pf, err := projectFields(p, r.Fields)
+		if err != nil {
+			return err
+		}
+		projected[i] = pf
+	}
+	return json.NewEncoder(w).Encode(projected)
+}
+
+// updateProfileRequest carries the decoded mobileconfig body for PUT
+// /management/v1/profiles/{uuid}; Profile.PayloadIdentifier is left
+// zero and is filled in by ReplaceProfile once it validates the
+// mobileconfig.
+type updateProfileRequest struct {
+	UUID string
+	Profile
+}
+
+type updateProfileResponse struct {
+	Profile *Profile `json:"profile,omitempty"`
+	Err     error    `json:"error,omitempty"`
+}
+
+func (r updateProfileResponse) error() error { return r.Err }
+
+// patchProfileRequest carries the raw JSON body for PATCH
+// /management/v1/profiles/{uuid}; Patch is diffed against the stored
+// profile so only fields present in the body are overwritten.
+type patchProfileRequest struct {
+	UUID  string
+	Patch []byte
+}
+
+type patchProfileResponse struct {
+	Profile *Profile `json:"profile,omitempty"`
+	Err     error    `json:"error,omitempty"`
+}
+
+func (r patchProfileResponse) error() error { return r.Err }
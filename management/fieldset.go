@@ -0,0 +1,26 @@
+package management
+
+import "encoding/json"
+
+// projectFields marshals v and returns only the keys named in fields. An
+// empty fields list is a no-op sentinel handled by the caller; this
+// helper always filters.
+func projectFields(v interface{}, fields []string) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var full map[string]interface{}
+	if err := json.Unmarshal(b, &full); err != nil {
+		return nil, err
+	}
+
+	projected := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if val, ok := full[f]; ok {
+			projected[f] = val
+		}
+	}
+	return projected, nil
+}
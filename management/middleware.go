@@ -0,0 +1,274 @@
+package management
+
+import (
+	"time"
+
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/opentracing/opentracing-go"
+	"golang.org/x/net/context"
+)
+
+// Middleware wraps a Service, decorating its methods the way
+// endpoint.Middleware decorates endpoints. It's used to layer
+// cross-cutting concerns (metrics, tracing, logging) around the
+// business logic without touching it.
+type Middleware func(Service) Service
+
+// NewInstrumentedService wraps svc with Prometheus request counters and
+// latency histograms, an OpenTracing span per call, and structured
+// logging, in that order (outermost to innermost).
+func NewInstrumentedService(svc Service, counter metrics.Counter, latency metrics.Histogram, tracer opentracing.Tracer, logger kitlog.Logger) Service {
+	svc = newLoggingMiddleware(logger)(svc)
+	svc = newTracingMiddleware(tracer)(svc)
+	svc = newInstrumentingMiddleware(counter, latency)(svc)
+	return svc
+}
+
+type instrumentingMiddleware struct {
+	requestCount   metrics.Counter
+	requestLatency metrics.Histogram
+	next           Service
+}
+
+func newInstrumentingMiddleware(counter metrics.Counter, latency metrics.Histogram) Middleware {
+	return func(next Service) Service {
+		return &instrumentingMiddleware{
+			requestCount:   counter,
+			requestLatency: latency,
+			next:           next,
+		}
+	}
+}
+
+func (mw instrumentingMiddleware) instrument(method string, begin time.Time, err error) {
+	lvs := []string{"method", method, "success", successLabel(err)}
+	mw.requestCount.With(lvs...).Add(1)
+	mw.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+}
+
+func successLabel(err error) string {
+	if err != nil {
+		return "false"
+	}
+	return "true"
+}
+
+func (mw instrumentingMiddleware) FetchDEPDevices(ctx context.Context, opts ListOpts) (devices []DEPDevice, nextCursor string, err error) {
+	defer func(begin time.Time) { mw.instrument("FetchDEPDevices", begin, err) }(time.Now())
+	return mw.next.FetchDEPDevices(ctx, opts)
+}
+
+func (mw instrumentingMiddleware) NewProfile(ctx context.Context, p Profile) (profile *Profile, err error) {
+	defer func(begin time.Time) { mw.instrument("NewProfile", begin, err) }(time.Now())
+	return mw.next.NewProfile(ctx, p)
+}
+
+func (mw instrumentingMiddleware) Profiles(ctx context.Context, opts ListOpts) (profiles []Profile, nextCursor string, err error) {
+	defer func(begin time.Time) { mw.instrument("Profiles", begin, err) }(time.Now())
+	return mw.next.Profiles(ctx, opts)
+}
+
+func (mw instrumentingMiddleware) Profile(ctx context.Context, uuid string) (profile *Profile, err error) {
+	defer func(begin time.Time) { mw.instrument("Profile", begin, err) }(time.Now())
+	return mw.next.Profile(ctx, uuid)
+}
+
+func (mw instrumentingMiddleware) DeleteProfile(ctx context.Context, uuid string) (err error) {
+	defer func(begin time.Time) { mw.instrument("DeleteProfile", begin, err) }(time.Now())
+	return mw.next.DeleteProfile(ctx, uuid)
+}
+
+func (mw instrumentingMiddleware) ReplaceProfile(ctx context.Context, uuid string, p Profile) (profile *Profile, err error) {
+	defer func(begin time.Time) { mw.instrument("ReplaceProfile", begin, err) }(time.Now())
+	return mw.next.ReplaceProfile(ctx, uuid, p)
+}
+
+func (mw instrumentingMiddleware) PatchProfile(ctx context.Context, uuid string, patch []byte) (profile *Profile, err error) {
+	defer func(begin time.Time) { mw.instrument("PatchProfile", begin, err) }(time.Now())
+	return mw.next.PatchProfile(ctx, uuid, patch)
+}
+
+func (mw instrumentingMiddleware) AssignProfile(ctx context.Context, udid, uuid string) (err error) {
+	defer func(begin time.Time) { mw.instrument("AssignProfile", begin, err) }(time.Now())
+	return mw.next.AssignProfile(ctx, udid, uuid)
+}
+
+func (mw instrumentingMiddleware) UnassignProfile(ctx context.Context, udid, uuid string) (err error) {
+	defer func(begin time.Time) { mw.instrument("UnassignProfile", begin, err) }(time.Now())
+	return mw.next.UnassignProfile(ctx, udid, uuid)
+}
+
+func (mw instrumentingMiddleware) DeviceProfiles(ctx context.Context, udid string) (profiles []Profile, err error) {
+	defer func(begin time.Time) { mw.instrument("DeviceProfiles", begin, err) }(time.Now())
+	return mw.next.DeviceProfiles(ctx, udid)
+}
+
+func (mw instrumentingMiddleware) ProfileDevices(ctx context.Context, uuid string) (udids []string, err error) {
+	defer func(begin time.Time) { mw.instrument("ProfileDevices", begin, err) }(time.Now())
+	return mw.next.ProfileDevices(ctx, uuid)
+}
+
+type tracingMiddleware struct {
+	tracer opentracing.Tracer
+	next   Service
+}
+
+func newTracingMiddleware(tracer opentracing.Tracer) Middleware {
+	return func(next Service) Service {
+		return &tracingMiddleware{tracer: tracer, next: next}
+	}
+}
+
+func (mw tracingMiddleware) span(ctx context.Context, method string) (context.Context, opentracing.Span) {
+	var span opentracing.Span
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		span = mw.tracer.StartSpan(method, opentracing.ChildOf(parent.Context()))
+	} else {
+		span = mw.tracer.StartSpan(method)
+	}
+	if id, ok := ctx.Value(requestIDHeader).(string); ok {
+		span.SetTag("request_id", id)
+	}
+	return opentracing.ContextWithSpan(ctx, span), span
+}
+
+func (mw tracingMiddleware) FetchDEPDevices(ctx context.Context, opts ListOpts) ([]DEPDevice, string, error) {
+	ctx, span := mw.span(ctx, "FetchDEPDevices")
+	defer span.Finish()
+	return mw.next.FetchDEPDevices(ctx, opts)
+}
+
+func (mw tracingMiddleware) NewProfile(ctx context.Context, p Profile) (*Profile, error) {
+	ctx, span := mw.span(ctx, "NewProfile")
+	defer span.Finish()
+	return mw.next.NewProfile(ctx, p)
+}
+
+func (mw tracingMiddleware) Profiles(ctx context.Context, opts ListOpts) ([]Profile, string, error) {
+	ctx, span := mw.span(ctx, "Profiles")
+	defer span.Finish()
+	return mw.next.Profiles(ctx, opts)
+}
+
+func (mw tracingMiddleware) Profile(ctx context.Context, uuid string) (*Profile, error) {
+	ctx, span := mw.span(ctx, "Profile")
+	defer span.Finish()
+	return mw.next.Profile(ctx, uuid)
+}
+
+func (mw tracingMiddleware) DeleteProfile(ctx context.Context, uuid string) error {
+	ctx, span := mw.span(ctx, "DeleteProfile")
+	defer span.Finish()
+	return mw.next.DeleteProfile(ctx, uuid)
+}
+
+func (mw tracingMiddleware) ReplaceProfile(ctx context.Context, uuid string, p Profile) (*Profile, error) {
+	ctx, span := mw.span(ctx, "ReplaceProfile")
+	defer span.Finish()
+	return mw.next.ReplaceProfile(ctx, uuid, p)
+}
+
+func (mw tracingMiddleware) PatchProfile(ctx context.Context, uuid string, patch []byte) (*Profile, error) {
+	ctx, span := mw.span(ctx, "PatchProfile")
+	defer span.Finish()
+	return mw.next.PatchProfile(ctx, uuid, patch)
+}
+
+func (mw tracingMiddleware) AssignProfile(ctx context.Context, udid, uuid string) error {
+	ctx, span := mw.span(ctx, "AssignProfile")
+	defer span.Finish()
+	return mw.next.AssignProfile(ctx, udid, uuid)
+}
+
+func (mw tracingMiddleware) UnassignProfile(ctx context.Context, udid, uuid string) error {
+	ctx, span := mw.span(ctx, "UnassignProfile")
+	defer span.Finish()
+	return mw.next.UnassignProfile(ctx, udid, uuid)
+}
+
+func (mw tracingMiddleware) DeviceProfiles(ctx context.Context, udid string) ([]Profile, error) {
+	ctx, span := mw.span(ctx, "DeviceProfiles")
+	defer span.Finish()
+	return mw.next.DeviceProfiles(ctx, udid)
+}
+
+func (mw tracingMiddleware) ProfileDevices(ctx context.Context, uuid string) ([]string, error) {
+	ctx, span := mw.span(ctx, "ProfileDevices")
+	defer span.Finish()
+	return mw.next.ProfileDevices(ctx, uuid)
+}
+
+type loggingMiddleware struct {
+	logger kitlog.Logger
+	next   Service
+}
+
+func newLoggingMiddleware(logger kitlog.Logger) Middleware {
+	return func(next Service) Service {
+		return &loggingMiddleware{logger: logger, next: next}
+	}
+}
+
+func (mw loggingMiddleware) log(method string, begin time.Time, err error) {
+	mw.logger.Log(
+		"method", method,
+		"took", time.Since(begin),
+		"err", err,
+	)
+}
+
+func (mw loggingMiddleware) FetchDEPDevices(ctx context.Context, opts ListOpts) (devices []DEPDevice, nextCursor string, err error) {
+	defer func(begin time.Time) { mw.log("FetchDEPDevices", begin, err) }(time.Now())
+	return mw.next.FetchDEPDevices(ctx, opts)
+}
+
+func (mw loggingMiddleware) NewProfile(ctx context.Context, p Profile) (profile *Profile, err error) {
+	defer func(begin time.Time) { mw.log("NewProfile", begin, err) }(time.Now())
+	return mw.next.NewProfile(ctx, p)
+}
+
+func (mw loggingMiddleware) Profiles(ctx context.Context, opts ListOpts) (profiles []Profile, nextCursor string, err error) {
+	defer func(begin time.Time) { mw.log("Profiles", begin, err) }(time.Now())
+	return mw.next.Profiles(ctx, opts)
+}
+
+func (mw loggingMiddleware) Profile(ctx context.Context, uuid string) (profile *Profile, err error) {
+	defer func(begin time.Time) { mw.log("Profile", begin, err) }(time.Now())
+	return mw.next.Profile(ctx, uuid)
+}
+
+func (mw loggingMiddleware) DeleteProfile(ctx context.Context, uuid string) (err error) {
+	defer func(begin time.Time) { mw.log("DeleteProfile", begin, err) }(time.Now())
+	return mw.next.DeleteProfile(ctx, uuid)
+}
+
+func (mw loggingMiddleware) ReplaceProfile(ctx context.Context, uuid string, p Profile) (profile *Profile, err error) {
+	defer func(begin time.Time) { mw.log("ReplaceProfile", begin, err) }(time.Now())
+	return mw.next.ReplaceProfile(ctx, uuid, p)
+}
+
+func (mw loggingMiddleware) PatchProfile(ctx context.Context, uuid string, patch []byte) (profile *Profile, err error) {
+	defer func(begin time.Time) { mw.log("PatchProfile", begin, err) }(time.Now())
+	return mw.next.PatchProfile(ctx, uuid, patch)
+}
+
+func (mw loggingMiddleware) AssignProfile(ctx context.Context, udid, uuid string) (err error) {
+	defer func(begin time.Time) { mw.log("AssignProfile", begin, err) }(time.Now())
+	return mw.next.AssignProfile(ctx, udid, uuid)
+}
+
+func (mw loggingMiddleware) UnassignProfile(ctx context.Context, udid, uuid string) (err error) {
+	defer func(begin time.Time) { mw.log("UnassignProfile", begin, err) }(time.Now())
+	return mw.next.UnassignProfile(ctx, udid, uuid)
+}
+
+func (mw loggingMiddleware) DeviceProfiles(ctx context.Context, udid string) (profiles []Profile, err error) {
+	defer func(begin time.Time) { mw.log("DeviceProfiles", begin, err) }(time.Now())
+	return mw.next.DeviceProfiles(ctx, udid)
+}
+
+func (mw loggingMiddleware) ProfileDevices(ctx context.Context, uuid string) (udids []string, err error) {
+	defer func(begin time.Time) { mw.log("ProfileDevices", begin, err) }(time.Now())
+	return mw.next.ProfileDevices(ctx, uuid)
+}
@@ -0,0 +1,41 @@
+package management
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// parseListOpts reads the shared list query parameters (limit, cursor,
+// identifier, fields) off an incoming request's query string.
+func parseListOpts(q url.Values) ListOpts {
+	opts := ListOpts{
+		Cursor:         q.Get("cursor"),
+		IdentifierGlob: q.Get("identifier"),
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if n, err := strconv.Atoi(limit); err == nil {
+			opts.Limit = n
+		}
+	}
+	if fields := q.Get("fields"); fields != "" {
+		opts.Fields = strings.Split(fields, ",")
+	}
+	return opts
+}
+
+// nextLinkHeader builds an RFC 5988 Link header pointing at the next
+// page of path, reusing the caller's original query parameters and
+// swapping in cursor. It returns "" when there is no next page.
+func nextLinkHeader(path string, query url.Values, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+	next := url.Values{}
+	for k, v := range query {
+		next[k] = v
+	}
+	next.Set("cursor", cursor)
+	return fmt.Sprintf(`<%s?%s>; rel="next"`, path, next.Encode())
+}
@@ -0,0 +1,109 @@
+package management
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// pagingStore is a minimal, test-only stand-in for a ProfileStore that
+// paginates by UUID order. No concrete ProfileStore ships in this tree
+// (only the interface, in service.go), so this exists solely to exercise
+// the cursor contract ListOpts/parseListOpts define: a cursor is "the
+// last UUID already returned", and the next page is everything greater.
+type pagingStore struct {
+	mu       sync.Mutex
+	profiles map[string]Profile
+}
+
+func newPagingStore() *pagingStore {
+	return &pagingStore{profiles: make(map[string]Profile)}
+}
+
+func (s *pagingStore) insert(p Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles[p.UUID] = p
+}
+
+func (s *pagingStore) list(opts ListOpts) (page []Profile, nextCursor string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uuids := make([]string, 0, len(s.profiles))
+	for uuid := range s.profiles {
+		if uuid > opts.Cursor {
+			uuids = append(uuids, uuid)
+		}
+	}
+	sort.Strings(uuids)
+	limit := opts.Limit
+	if limit == 0 || limit > len(uuids) {
+		limit = len(uuids)
+	}
+	page = make([]Profile, limit)
+	for i, uuid := range uuids[:limit] {
+		page[i] = s.profiles[uuid]
+	}
+	if limit < len(uuids) {
+		nextCursor = uuids[limit-1]
+	}
+	return page, nextCursor
+}
+
+// TestPaginationStableAcrossConcurrentInserts pages through a store
+// while inserts land concurrently, both before and after UUIDs already
+// handed back, and asserts every profile is returned at most once: a
+// cursor of "the last UUID seen" must stay valid no matter what else
+// gets inserted while the caller is still paging.
+func TestPaginationStableAcrossConcurrentInserts(t *testing.T) {
+	store := newPagingStore()
+	const initial = 50
+	for i := 0; i < initial; i++ {
+		store.insert(Profile{UUID: fmt.Sprintf("profile-%04d", i)})
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			i := 0
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				store.insert(Profile{UUID: fmt.Sprintf("profile-concurrent-%d-%04d", worker, i)})
+				i++
+			}
+		}(w)
+	}
+
+	seen := make(map[string]bool)
+	cursor := ""
+	for {
+		page, next := store.list(ListOpts{Limit: 7, Cursor: cursor})
+		if len(page) == 0 {
+			break
+		}
+		for _, p := range page {
+			if seen[p.UUID] {
+				t.Fatalf("profile %s returned twice across pages", p.UUID)
+			}
+			seen[p.UUID] = true
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	close(stop)
+	wg.Wait()
+
+	if len(seen) < initial {
+		t.Fatalf("expected to see at least the %d initial profiles inserted before paging began, saw %d", initial, len(seen))
+	}
+}
@@ -0,0 +1,40 @@
+package management
+
+import "encoding/json"
+
+// mergeProfilePatch overlays the fields present in patch onto existing,
+// leaving any field absent from patch untouched. Presence, not
+// zero-ness, decides whether a field is overwritten: {"identifier":""}
+// clears PayloadIdentifier, while omitting the key entirely preserves
+// the stored value.
+func mergeProfilePatch(existing Profile, patch []byte) (Profile, error) {
+	base, err := json.Marshal(existing)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(base, &merged); err != nil {
+		return Profile{}, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &fields); err != nil {
+		return Profile{}, err
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	out, err := json.Marshal(merged)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var result Profile
+	if err := json.Unmarshal(out, &result); err != nil {
+		return Profile{}, err
+	}
+	result.UUID = existing.UUID
+	return result, nil
+}
@@ -0,0 +1,38 @@
+package management
+
+// Profile represents an Apple configuration profile (.mobileconfig) that
+// can be pushed to enrolled devices via MDM.
+type Profile struct {
+	UUID              string `json:"uuid"`
+	PayloadIdentifier string `json:"identifier"`
+	Mobileconfig      []byte `json:"mobileconfig"`
+}
+
+// ListOpts controls pagination, filtering and sparse fieldsets for the
+// profile and DEP device list endpoints.
+type ListOpts struct {
+	// Limit caps the number of items returned. A zero value means the
+	// service's default page size.
+	Limit int
+
+	// Cursor resumes a previous listing where it left off. An empty
+	// cursor starts from the beginning.
+	Cursor string
+
+	// IdentifierGlob, if set, restricts results to profiles whose
+	// PayloadIdentifier matches the glob pattern.
+	IdentifierGlob string
+
+	// Fields, if non-empty, requests a sparse fieldset: only these
+	// top-level JSON keys are included in the encoded response.
+	Fields []string
+}
+
+// DEPDevice is a device returned from the DEP fetch devices API.
+type DEPDevice struct {
+	SerialNumber string `json:"serial_number"`
+	Model        string `json:"model"`
+	Description  string `json:"description"`
+	Color        string `json:"color"`
+	AssetTag     string `json:"asset_tag"`
+}
@@ -0,0 +1,143 @@
+package management
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fullsailor/pkcs7"
+	"github.com/groob/plist"
+)
+
+type testMobileconfig struct {
+	PayloadIdentifier string                   `plist:"PayloadIdentifier"`
+	PayloadUUID       string                   `plist:"PayloadUUID"`
+	PayloadType       string                   `plist:"PayloadType"`
+	PayloadVersion    int                      `plist:"PayloadVersion"`
+	PayloadContent    []map[string]interface{} `plist:"PayloadContent"`
+}
+
+func validMobileconfigPlist(t *testing.T) []byte {
+	t.Helper()
+	mc := testMobileconfig{
+		PayloadIdentifier: "com.example.test",
+		PayloadUUID:       "11111111-1111-1111-1111-111111111111",
+		PayloadType:       "Configuration",
+		PayloadVersion:    1,
+		PayloadContent: []map[string]interface{}{
+			{"PayloadUUID": "22222222-2222-2222-2222-222222222222", "PayloadIdentifier": "com.example.test.payload"},
+		},
+	}
+	b, err := plist.Marshal(mc)
+	if err != nil {
+		t.Fatalf("marshal plist: %v", err)
+	}
+	return b
+}
+
+func makeTestCA(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func makeTestLeaf(t *testing.T, ca *x509.Certificate, caKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func signTestPlist(t *testing.T, content []byte, leaf *x509.Certificate, leafKey *rsa.PrivateKey) []byte {
+	t.Helper()
+	sd, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.AddSigner(leaf, leafKey, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// TestDecodeMobileconfigSignedAgainstTrustedCA guards against a
+// regression to pkcs7.VerifyWithChain, which doesn't exist on the real
+// fullsailor/pkcs7 API and previously made this package fail to build.
+func TestDecodeMobileconfigSignedAgainstTrustedCA(t *testing.T) {
+	ca, caKey := makeTestCA(t)
+	leaf, leafKey := makeTestLeaf(t, ca, caKey)
+	signed := signTestPlist(t, validMobileconfigPlist(t), leaf, leafKey)
+
+	trusted := x509.NewCertPool()
+	trusted.AddCert(ca)
+
+	encoded := base64.StdEncoding.EncodeToString(signed)
+	p, err := decodeMobileconfig([]byte(encoded), true, trusted)
+	if err != nil {
+		t.Fatalf("decodeMobileconfig: %v", err)
+	}
+	if p.UUID != "11111111-1111-1111-1111-111111111111" {
+		t.Errorf("UUID = %q, want the PayloadUUID", p.UUID)
+	}
+}
+
+func TestDecodeMobileconfigRejectsUntrustedSigner(t *testing.T) {
+	ca, caKey := makeTestCA(t)
+	leaf, leafKey := makeTestLeaf(t, ca, caKey)
+	signed := signTestPlist(t, validMobileconfigPlist(t), leaf, leafKey)
+
+	otherCA, _ := makeTestCA(t)
+	untrusted := x509.NewCertPool()
+	untrusted.AddCert(otherCA)
+
+	encoded := base64.StdEncoding.EncodeToString(signed)
+	if _, err := decodeMobileconfig([]byte(encoded), true, untrusted); err == nil {
+		t.Fatal("expected decodeMobileconfig to reject a signer outside the trust bundle, got nil error")
+	}
+}
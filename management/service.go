@@ -0,0 +1,204 @@
+package management
+
+import (
+	"crypto/x509"
+	"errors"
+
+	"github.com/micromdm/micromdm/command"
+	"golang.org/x/net/context"
+)
+
+// ErrNotFound is returned when a requested resource does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Service defines methods for the management service, which exposes
+// operations for managing DEP devices and MDM configuration profiles.
+type Service interface {
+	// FetchDEPDevices returns a page of the DEP fleet according to opts,
+	// along with the cursor for the following page, if any.
+	FetchDEPDevices(ctx context.Context, opts ListOpts) (devices []DEPDevice, nextCursor string, err error)
+
+	NewProfile(ctx context.Context, p Profile) (*Profile, error)
+
+	// Profiles returns a page of stored profiles according to opts,
+	// along with the cursor for the following page, if any.
+	Profiles(ctx context.Context, opts ListOpts) (profiles []Profile, nextCursor string, err error)
+
+	// Profile returns the stored profile identified by uuid.
+	//
+	// @route GET /management/v1/profiles/{uuid}
+	// @path uuid
+	// @name showProfile
+	Profile(ctx context.Context, uuid string) (*Profile, error)
+
+	// DeleteProfile removes the profile identified by uuid.
+	//
+	// @route DELETE /management/v1/profiles/{uuid}
+	// @path uuid
+	// @name deleteProfile
+	DeleteProfile(ctx context.Context, uuid string) error
+
+	// ReplaceProfile overwrites the profile identified by uuid with p in
+	// its entirety. p.Mobileconfig is validated the same way as a new
+	// upload before it's stored.
+	ReplaceProfile(ctx context.Context, uuid string, p Profile) (*Profile, error)
+
+	// PatchProfile applies the fields present in patch to the stored
+	// profile identified by uuid, leaving absent fields untouched. The
+	// resulting Mobileconfig is validated the same way as a new upload
+	// before it's stored.
+	PatchProfile(ctx context.Context, uuid string, patch []byte) (*Profile, error)
+
+	// AssignProfile installs the profile identified by uuid on the
+	// device identified by udid.
+	//
+	// @route POST /management/v1/devices/{udid}/profiles
+	// @path udid
+	// @name assignProfile
+	AssignProfile(ctx context.Context, udid, uuid string) error
+
+	// UnassignProfile removes the profile identified by uuid from the
+	// device identified by udid.
+	//
+	// @route DELETE /management/v1/devices/{udid}/profiles/{uuid}
+	// @path udid
+	// @path uuid
+	// @name unassignProfile
+	UnassignProfile(ctx context.Context, udid, uuid string) error
+
+	// DeviceProfiles lists the profiles assigned to a device.
+	//
+	// @route GET /management/v1/devices/{udid}/profiles
+	// @path udid
+	// @name listDeviceProfiles
+	DeviceProfiles(ctx context.Context, udid string) ([]Profile, error)
+
+	// ProfileDevices lists the udids of devices a profile has been
+	// assigned to, whether the install has completed or is still
+	// queued.
+	//
+	// @route GET /management/v1/profiles/{uuid}/devices
+	// @path uuid
+	// @name listProfileDevices
+	ProfileDevices(ctx context.Context, uuid string) ([]string, error)
+}
+
+// ProfileStore persists configuration profiles and their device
+// assignments.
+type ProfileStore interface {
+	NewProfile(p Profile) (*Profile, error)
+
+	// Profiles returns a page of profiles matching opts and the cursor
+	// to resume listing after the last item returned, or "" if there
+	// are no more results.
+	Profiles(opts ListOpts) (profiles []Profile, nextCursor string, err error)
+	ProfileByUUID(uuid string) (*Profile, error)
+	SaveProfile(p Profile) (*Profile, error)
+	DeleteProfile(uuid string) error
+
+	AssignProfile(udid, uuid string) error
+	UnassignProfile(udid, uuid string) error
+	ProfilesByUDID(udid string) ([]Profile, error)
+	UDIDsByProfile(uuid string) ([]string, error)
+}
+
+type service struct {
+	store        ProfileStore
+	cmdSvc       command.Service
+	trustAnchors *x509.CertPool
+}
+
+// New creates a management Service backed by store, using cmdSvc to
+// enqueue MDM commands triggered by profile assignment changes.
+// trustAnchors is used to verify CMS-signed profiles submitted via
+// ReplaceProfile and PatchProfile; it may be nil to accept signed
+// profiles without verification. NewProfile stores p as given and does
+// not validate it; POST uploads are validated by the transport's
+// decoder against the trust anchors passed to ServiceHandler.
+func New(store ProfileStore, cmdSvc command.Service, trustAnchors *x509.CertPool) Service {
+	return &service{store: store, cmdSvc: cmdSvc, trustAnchors: trustAnchors}
+}
+
+func (svc *service) FetchDEPDevices(ctx context.Context, opts ListOpts) ([]DEPDevice, string, error) {
+	return nil, "", errors.New("not implemented")
+}
+
+func (svc *service) NewProfile(ctx context.Context, p Profile) (*Profile, error) {
+	return svc.store.NewProfile(p)
+}
+
+func (svc *service) Profiles(ctx context.Context, opts ListOpts) ([]Profile, string, error) {
+	return svc.store.Profiles(opts)
+}
+
+func (svc *service) Profile(ctx context.Context, uuid string) (*Profile, error) {
+	return svc.store.ProfileByUUID(uuid)
+}
+
+func (svc *service) DeleteProfile(ctx context.Context, uuid string) error {
+	return svc.store.DeleteProfile(uuid)
+}
+
+func (svc *service) ReplaceProfile(ctx context.Context, uuid string, p Profile) (*Profile, error) {
+	validated, err := decodeMobileconfig(p.Mobileconfig, false, svc.trustAnchors)
+	if err != nil {
+		return nil, err
+	}
+	p.PayloadIdentifier = validated.PayloadIdentifier
+	p.Mobileconfig = validated.Mobileconfig
+	p.UUID = uuid
+	return svc.store.SaveProfile(p)
+}
+
+func (svc *service) PatchProfile(ctx context.Context, uuid string, patch []byte) (*Profile, error) {
+	existing, err := svc.store.ProfileByUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := mergeProfilePatch(*existing, patch)
+	if err != nil {
+		return nil, err
+	}
+	validated, err := decodeMobileconfig(merged.Mobileconfig, false, svc.trustAnchors)
+	if err != nil {
+		return nil, err
+	}
+	merged.PayloadIdentifier = validated.PayloadIdentifier
+	merged.Mobileconfig = validated.Mobileconfig
+	return svc.store.SaveProfile(merged)
+}
+
+func (svc *service) AssignProfile(ctx context.Context, udid, uuid string) error {
+	if _, err := svc.store.ProfileByUUID(uuid); err != nil {
+		return err
+	}
+	if err := svc.store.AssignProfile(udid, uuid); err != nil {
+		return err
+	}
+	_, err := svc.cmdSvc.NewCommand(ctx, &command.CommandRequest{
+		UDID:        udid,
+		RequestType: "InstallProfile",
+		ProfileUUID: uuid,
+	})
+	return err
+}
+
+func (svc *service) UnassignProfile(ctx context.Context, udid, uuid string) error {
+	if err := svc.store.UnassignProfile(udid, uuid); err != nil {
+		return err
+	}
+	_, err := svc.cmdSvc.NewCommand(ctx, &command.CommandRequest{
+		UDID:        udid,
+		RequestType: "RemoveProfile",
+		ProfileUUID: uuid,
+	})
+	return err
+}
+
+func (svc *service) DeviceProfiles(ctx context.Context, udid string) ([]Profile, error) {
+	return svc.store.ProfilesByUDID(udid)
+}
+
+func (svc *service) ProfileDevices(ctx context.Context, uuid string) ([]string, error) {
+	return svc.store.UDIDsByProfile(uuid)
+}
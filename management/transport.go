@@ -1,25 +1,51 @@
 package management
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net/http"
 
 	kitlog "github.com/go-kit/kit/log"
 	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/mux"
 	"github.com/micromdm/micromdm/workflow"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/net/context"
 )
 
 var errBadUUID = errors.New("request must have a valid uuid")
 
-// ServiceHandler returns an HTTP Handler for the management service
-func ServiceHandler(ctx context.Context, svc Service, logger kitlog.Logger) http.Handler {
+// requestIDHeader, if present on an incoming request, seeds the root
+// span so traces can be correlated with the client's own request id.
+// traceparent is accepted too, for callers that speak the W3C format.
+const requestIDHeader = "X-Request-ID"
+
+func extractRequestID(ctx context.Context, r *http.Request) context.Context {
+	id := r.Header.Get(requestIDHeader)
+	if id == "" {
+		id = r.Header.Get("traceparent")
+	}
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDHeader, id)
+}
+
+// ServiceHandler returns an HTTP Handler for the management service. svc
+// is expected to already be wrapped with NewInstrumentedService by the
+// caller; ServiceHandler itself only wires transport-level concerns.
+// trustAnchors, typically loaded via LoadProfileTrustAnchors from a PEM
+// bundle named by a server flag, is used to verify CMS-signed profile
+// uploads; it may be nil to accept signed profiles without
+// verification.
+func ServiceHandler(ctx context.Context, svc Service, logger kitlog.Logger, trustAnchors *x509.CertPool) http.Handler {
 	opts := []kithttp.ServerOption{
 		kithttp.ServerErrorLogger(logger),
 		kithttp.ServerErrorEncoder(encodeError),
+		kithttp.ServerBefore(extractRequestID),
 	}
 
 	fetchDEPHandler := kithttp.NewServer(
@@ -33,7 +59,7 @@ func ServiceHandler(ctx context.Context, svc Service, logger kitlog.Logger) http
 	addProfileHandler := kithttp.NewServer(
 		ctx,
 		makeAddProfileEndpoint(svc),
-		decodeAddProfileRequest,
+		decodeAddProfileRequest(trustAnchors),
 		encodeResponse,
 		opts...,
 	)
@@ -58,6 +84,48 @@ func ServiceHandler(ctx context.Context, svc Service, logger kitlog.Logger) http
 		encodeResponse,
 		opts...,
 	)
+	updateProfileHandler := kithttp.NewServer(
+		ctx,
+		makeUpdateProfileEndpoint(svc),
+		decodeUpdateProfileRequest,
+		encodeResponse,
+		opts...,
+	)
+	patchProfileHandler := kithttp.NewServer(
+		ctx,
+		makePatchProfileEndpoint(svc),
+		decodePatchProfileRequest,
+		encodeResponse,
+		opts...,
+	)
+	listDeviceProfilesHandler := kithttp.NewServer(
+		ctx,
+		makeListDeviceProfilesEndpoint(svc),
+		decodeListDeviceProfilesRequest,
+		encodeResponse,
+		opts...,
+	)
+	assignProfileHandler := kithttp.NewServer(
+		ctx,
+		makeAssignProfileEndpoint(svc),
+		decodeAssignProfileRequest,
+		encodeResponse,
+		opts...,
+	)
+	unassignProfileHandler := kithttp.NewServer(
+		ctx,
+		makeUnassignProfileEndpoint(svc),
+		decodeUnassignProfileRequest,
+		encodeResponse,
+		opts...,
+	)
+	listProfileDevicesHandler := kithttp.NewServer(
+		ctx,
+		makeListProfileDevicesEndpoint(svc),
+		decodeListProfileDevicesRequest,
+		encodeResponse,
+		opts...,
+	)
 
 	r := mux.NewRouter()
 
@@ -65,55 +133,144 @@ func ServiceHandler(ctx context.Context, svc Service, logger kitlog.Logger) http
 	r.Handle("/management/v1/profiles", addProfileHandler).Methods("POST")
 	r.Handle("/management/v1/profiles", listProfilesHandler).Methods("GET")
 	r.Handle("/management/v1/profiles/{uuid}", showProfileHandler).Methods("GET")
+	r.Handle("/management/v1/profiles/{uuid}", updateProfileHandler).Methods("PUT")
+	r.Handle("/management/v1/profiles/{uuid}", patchProfileHandler).Methods("PATCH")
 	r.Handle("/management/v1/profiles/{uuid}", deleteProfileHandler).Methods("DELETE")
+	r.Handle("/management/v1/profiles/{uuid}/devices", listProfileDevicesHandler).Methods("GET")
+	r.Handle("/management/v1/devices/{udid}/profiles", listDeviceProfilesHandler).Methods("GET")
+	r.Handle("/management/v1/devices/{udid}/profiles", assignProfileHandler).Methods("POST")
+	r.Handle("/management/v1/devices/{udid}/profiles/{uuid}", unassignProfileHandler).Methods("DELETE")
+
+	// /metrics and /healthz are scrape/liveness endpoints, not business
+	// logic, so they bypass the instrumented Service entirely and must
+	// not be wrapped in the metrics/tracing middleware above.
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", healthzHandler).Methods("GET")
 
 	return r
 }
 
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
 func decodeFetchDEPDevicesRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	return fetchDEPDevicesRequest{}, nil
+	q := r.URL.Query()
+	return fetchDEPDevicesRequest{ListOpts: parseListOpts(q), query: q}, nil
 }
 
-func decodeAddProfileRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	var request addProfileRequest
-	err := json.NewDecoder(r.Body).Decode(&request)
-	if err == io.EOF {
-		return nil, errEmptyRequest
-	}
-	if request.PayloadIdentifier == "" {
-		return nil, errEmptyRequest
+// decodeAddProfileRequest returns a decoder that parses and validates
+// the uploaded .mobileconfig, verifying its CMS signature against
+// trustAnchors when the upload is signed.
+func decodeAddProfileRequest(trustAnchors *x509.CertPool) kithttp.DecodeRequestFunc {
+	return func(_ context.Context, r *http.Request) (interface{}, error) {
+		var body struct {
+			Mobileconfig string `json:"mobileconfig"`
+			Encoding     string `json:"encoding"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			if err == io.EOF {
+				return nil, errEmptyRequest
+			}
+			return nil, err
+		}
+		if body.Mobileconfig == "" {
+			return nil, errEmptyRequest
+		}
+		profile, err := decodeMobileconfig([]byte(body.Mobileconfig), body.Encoding == "base64", trustAnchors)
+		if err != nil {
+			return nil, err
+		}
+		return addProfileRequest{Profile: *profile}, nil
 	}
-	return request, err
 }
 
 func decodeListProfilesRequest(_ context.Context, r *http.Request) (interface{}, error) {
-	return listProfilesRequest{}, nil
+	q := r.URL.Query()
+	return listProfilesRequest{ListOpts: parseListOpts(q), query: q}, nil
 }
 
-func decodeShowProfileRequest(_ context.Context, r *http.Request) (interface{}, error) {
+// decodeUpdateProfileRequest reads the same {mobileconfig, encoding}
+// body as POST, rather than a plain Profile - encoding/json would
+// otherwise base64-decode Profile.Mobileconfig unconditionally,
+// rejecting exactly the raw, unencoded mobileconfig bodies POST
+// accepts. ReplaceProfile validates the decoded bytes.
+func decodeUpdateProfileRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	vars := mux.Vars(r)
 	uuid, ok := vars["uuid"]
 	if !ok {
 		return nil, errBadRouting
 	}
-	// simple validation
 	if len(uuid) != 36 {
 		return nil, errBadUUID
 	}
-	return showProfileRequest{UUID: uuid}, nil
+	var body struct {
+		Mobileconfig string `json:"mobileconfig"`
+		Encoding     string `json:"encoding"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		if err == io.EOF {
+			return nil, errEmptyRequest
+		}
+		return nil, err
+	}
+	if body.Mobileconfig == "" {
+		return nil, errEmptyRequest
+	}
+	mobileconfig, err := decodeMobileconfigBody([]byte(body.Mobileconfig), body.Encoding == "base64")
+	if err != nil {
+		return nil, err
+	}
+	return updateProfileRequest{UUID: uuid, Profile: Profile{Mobileconfig: mobileconfig}}, nil
 }
 
-func decodeDeleteProfileRequest(_ context.Context, r *http.Request) (interface{}, error) {
+// decodePatchProfileRequest reads the same {mobileconfig, encoding}
+// body as POST and PUT, then re-expresses it as the internal
+// mergeProfilePatch representation - a JSON object keyed by Profile's
+// own field tags - so presence of "mobileconfig" in the request still
+// means "replace", and its absence still means "leave untouched".
+func decodePatchProfileRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	vars := mux.Vars(r)
 	uuid, ok := vars["uuid"]
 	if !ok {
 		return nil, errBadRouting
 	}
-	// simple validation
 	if len(uuid) != 36 {
 		return nil, errBadUUID
 	}
-	return deleteProfileRequest{UUID: uuid}, nil
+	raw, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, errEmptyRequest
+	}
+	var body struct {
+		Mobileconfig *string `json:"mobileconfig"`
+		Encoding     string  `json:"encoding"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+
+	patch := map[string]json.RawMessage{}
+	if body.Mobileconfig != nil {
+		mobileconfig, err := decodeMobileconfigBody([]byte(*body.Mobileconfig), body.Encoding == "base64")
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := json.Marshal(mobileconfig)
+		if err != nil {
+			return nil, err
+		}
+		patch["mobileconfig"] = encoded
+	}
+	patchJSON, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+	return patchProfileRequest{UUID: uuid, Patch: patchJSON}, nil
 }
 
 func encodeResponse(ctx context.Context, w http.ResponseWriter, response interface{}) error {
@@ -122,6 +279,13 @@ func encodeResponse(ctx context.Context, w http.ResponseWriter, response interfa
 		return nil
 	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	// paginated collections advertise the next page via a Link header;
+	// this must be set before WriteHeader below.
+	if e, ok := response.(linker); ok {
+		if link := e.linkHeader(); link != "" {
+			w.Header().Set("Link", link)
+		}
+	}
 	// for success responses
 	if e, ok := response.(statuser); ok {
 		w.WriteHeader(e.status())
@@ -150,12 +314,25 @@ type listEncoder interface {
 	encodeList(w http.ResponseWriter) error
 }
 
+type linker interface {
+	linkHeader() string
+}
+
 // encode errors from business-logic
 func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 	// unwrap if the error is wrapped by kit http in it's own error type
 	if httperr, ok := err.(kithttp.Error); ok {
 		err = httperr.Err
 	}
+	if invalid, ok := err.(*errInvalidProfile); ok {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": invalid.Error(),
+			"field": invalid.Field,
+		})
+		return
+	}
 	switch err {
 	case ErrNotFound:
 		w.WriteHeader(http.StatusNotFound)